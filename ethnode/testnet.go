@@ -0,0 +1,12 @@
+package ethnode
+
+// IsTestnet returns whether id is a known test network (as opposed to
+// mainnet or an unrecognized chain). Used to gate testnet-only features
+// like the pool faucet.
+func (id NetworkID) IsTestnet() bool {
+	switch {
+	case id.Is("ropsten"), id.Is("rinkeby"), id.Is("goerli"), id.Is("sepolia"):
+		return true
+	}
+	return false
+}
@@ -0,0 +1,45 @@
+package main
+
+import "time"
+
+// Options is the top-level CLI flag structure, parsed by the command-line
+// entrypoint and passed to the relevant run* function (e.g. runPool).
+type Options struct {
+	Pool PoolOptions `command:"pool" description:"Run a vipnode pool"`
+}
+
+// PoolOptions are the flags for the `vipnode pool` subcommand.
+type PoolOptions struct {
+	Bind        string `long:"bind" default:":8080" description:"Address to bind the pool's RPC server on."`
+	TLSHost     string `long:"tlshost" description:"Hostname to request an ACME (Let's Encrypt) certificate for, and serve TLS on :443."`
+	AllowOrigin string `long:"allow-origin" description:"Value for the Access-Control-Allow-Origin header. Leave empty to disable CORS."`
+
+	Store   string `long:"store" default:"memory" description:"Storage driver to use: memory, badger, postgres."`
+	DataDir string `long:"datadir" description:"Directory to store persistent data in, when --store=badger. Defaults to an XDG data directory."`
+
+	MaxRequestHosts int `long:"max-request-hosts" default:"3" description:"Default number of hosts to offer a client that didn't specify ClientRequest.NumHosts."`
+
+	Contract ContractOptions `group:"Payment contract options"`
+
+	FaucetKeyStore string        `long:"faucet-keystore" description:"Path to a keystore used to fund the testnet faucet. Enables the faucet subsystem."`
+	FaucetAmount   string        `long:"faucet-amount" default:"0.01 ether" description:"Amount credited per successful faucet claim."`
+	FaucetCooldown time.Duration `long:"faucet-cooldown" default:"24h" description:"Minimum time between faucet claims for the same NodeID or IP."`
+	FaucetDailyCap string        `long:"faucet-daily-cap" default:"0.1 ether" description:"Maximum total amount a single NodeID can claim from the faucet per rolling 24h window."`
+
+	WithdrawFeeMultiplier float64 `long:"withdraw-fee-multiplier" default:"1.5" description:"Safety margin applied over the estimated settle gas cost when pricing the withdrawal fee."`
+	WithdrawFeeMin        string  `long:"withdraw-fee-min" default:"0.001 ether" description:"Floor on the dynamically-priced withdrawal fee."`
+	WithdrawFeeMax        string  `long:"withdraw-fee-max" default:"0.01 ether" description:"Ceiling on the dynamically-priced withdrawal fee."`
+
+	GasOracle                     string  `long:"gas-oracle" default:"legacy" description:"Gas price oracle used to price withdrawal fees: legacy (eth_gasPrice via ethclient.SuggestGasPrice), feehistory (EIP-1559 eth_feeHistory percentile)."`
+	GasOracleFeeHistoryPercentile float64 `long:"gas-oracle-feehistory-percentile" default:"50" description:"Reward percentile (0-100) sampled from eth_feeHistory when --gas-oracle=feehistory."`
+}
+
+// ContractOptions configure the payment contract used by the pool.
+type ContractOptions struct {
+	Addr       string `long:"contract" description:"Payment contract address, as a <network>://<address> URI, e.g. mainnet://0x1234..."`
+	RPC        string `long:"contract-rpc" description:"Ethereum JSON-RPC endpoint to interact with the payment contract, or just to detect the pool's network (e.g. for the faucet) if --contract is unset."`
+	KeyStore   string `long:"contract-keystore" description:"Path to the keystore for the contract operator wallet. Read-only mode if unset."`
+	Price      string `long:"contract-price" default:"0 ether" description:"Credit accrued per billing interval."`
+	MinBalance string `long:"contract-min-balance" default:"0.01 ether" description:"Minimum balance a client must maintain, or \"off\" to disable."`
+	Welcome    string `long:"contract-welcome" description:"Override the welcome message template sent to newly connected clients."`
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
@@ -15,6 +16,7 @@ import (
 
 	"github.com/OpenPeeDeeP/xdg"
 	"github.com/dgraph-io/badger"
+	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/ethclient"
@@ -23,11 +25,13 @@ import (
 	ws "github.com/vipnode/vipnode/jsonrpc2/ws/gorilla"
 	"github.com/vipnode/vipnode/pool"
 	"github.com/vipnode/vipnode/pool/balance"
+	"github.com/vipnode/vipnode/pool/faucet"
 	"github.com/vipnode/vipnode/pool/payment"
 	"github.com/vipnode/vipnode/pool/status"
 	"github.com/vipnode/vipnode/pool/store"
 	badgerStore "github.com/vipnode/vipnode/pool/store/badger"
 	memoryStore "github.com/vipnode/vipnode/pool/store/memory"
+	sqlStore "github.com/vipnode/vipnode/pool/store/sql"
 	"golang.org/x/crypto/acme/autocert"
 )
 
@@ -48,7 +52,9 @@ func runPool(options Options) error {
 	var storeDriver store.Store
 	switch options.Pool.Store {
 	case "memory":
-		storeDriver = memoryStore.New()
+		s := memoryStore.New()
+		s.Cooldown = options.Pool.FaucetCooldown
+		storeDriver = s
 		defer storeDriver.Close()
 	case "persist":
 		fallthrough
@@ -60,12 +66,30 @@ func runPool(options Options) error {
 		badgerOpts := badger.DefaultOptions
 		badgerOpts.Dir = dir
 		badgerOpts.ValueDir = dir
-		storeDriver, err = badgerStore.Open(badgerOpts)
+		s, err := badgerStore.Open(badgerOpts)
 		if err != nil {
 			return err
 		}
+		s.Cooldown = options.Pool.FaucetCooldown
+		storeDriver = s
 		defer storeDriver.Close()
 		logger.Infof("Persistent store using badger backend: %s", dir)
+	case "postgres":
+		dsn := os.Getenv("POSTGRES_URL")
+		if dsn == "" {
+			return ErrExplain{
+				errors.New("missing POSTGRES_URL"),
+				"--store=postgres requires the POSTGRES_URL environment variable to be set to a Postgres connection string, with the pool/store/sql/migrations schema already applied.",
+			}
+		}
+		s, err := sqlStore.Open(dsn)
+		if err != nil {
+			return err
+		}
+		s.Cooldown = options.Pool.FaucetCooldown
+		storeDriver = s
+		defer storeDriver.Close()
+		logger.Infof("Persistent store using postgres backend")
 	default:
 		return errors.New("storage driver not implemented")
 	}
@@ -73,82 +97,146 @@ func runPool(options Options) error {
 	balanceStore := store.BalanceStore(storeDriver)
 	var settleHandler payment.SettleHandler
 	var depositGetter func(ctx context.Context) (*big.Int, error)
-	if options.Pool.Contract.Addr != "" {
-		// Payment contract implements NodeBalanceStore used by the balance
-		// manager, but with contract awareness.
-		contractPath, err := url.Parse(options.Pool.Contract.Addr)
-		if err != nil {
-			return err
-		}
-
-		contractAddr := common.HexToAddress(contractPath.Hostname())
-		network := contractPath.Scheme
+	var poolNetwork ethnode.NetworkID
+	var haveContract bool
+	var gasOracle payment.GasOracle
+	var contractAddr common.Address
+	var settleGasEstimate uint64
+	if options.Pool.Contract.RPC != "" {
+		// Dial the RPC endpoint and detect its network even if we're not
+		// using a payment contract, so that network-gated features (like
+		// the faucet below) still work for pools that credit balances
+		// directly instead of through a contract.
 		ethclient, err := ethclient.Dial(options.Pool.Contract.RPC)
 		if err != nil {
 			return err
 		}
 
-		// Confirm we're on the right network
 		gotNetwork, err := ethclient.NetworkID(context.Background())
 		if err != nil {
 			return err
 		}
-		if networkID := ethnode.NetworkID(int(gotNetwork.Int64())); !networkID.Is(network) {
-			return ErrExplain{
-				errors.New("ethereum network mismatch for payment contract"),
-				fmt.Sprintf("Contract is on %q while the Contact RPC is a %q node. Please provide a Contract RPC on the same network as the contract.", network, networkID),
-			}
-		}
+		poolNetwork = ethnode.NetworkID(int(gotNetwork.Int64()))
 
-		var transactOpts *bind.TransactOpts
-		if options.Pool.Contract.KeyStore != "" {
-			transactOpts, err = unlockTransactor(options.Pool.Contract.KeyStore)
+		if options.Pool.Contract.Addr != "" {
+			// Payment contract implements NodeBalanceStore used by the balance
+			// manager, but with contract awareness.
+			contractPath, err := url.Parse(options.Pool.Contract.Addr)
 			if err != nil {
-				return ErrExplain{
-					err,
-					"Failed to unlock the keystore for the contract operator wallet. Make sure the path is correct and the decryption password is set in the `KEYSTORE_PASSPHRASE` environment variable.",
-				}
+				return err
 			}
-		}
 
-		if transactOpts == nil {
-			logger.Warningf("Contract payment starting in read-only mode because --contract-keystore was not set. Withdraw and settlement attempts will fail.")
-		}
+			contractAddr = common.HexToAddress(contractPath.Hostname())
+			network := contractPath.Scheme
 
-		contract, err := payment.ContractPayment(storeDriver, contractAddr, ethclient, transactOpts)
-		if err != nil {
-			if err, ok := err.(payment.AddressMismatchError); ok {
+			// Quote the gas price as-is; the withdraw fee safety margin is
+			// applied once, by DynamicWithdrawFee below.
+			switch options.Pool.GasOracle {
+			case "", "legacy":
+				gasOracle = &payment.EthclientGasOracle{
+					Client: ethclient,
+				}
+			case "feehistory":
+				gasOracle = &payment.FeeHistoryGasOracle{
+					Client:     ethclient,
+					Percentile: options.Pool.GasOracleFeeHistoryPercentile,
+				}
+			default:
+				return fmt.Errorf("unrecognized --gas-oracle value: %q", options.Pool.GasOracle)
+			}
+
+			// Confirm we're on the right network
+			if !poolNetwork.Is(network) {
 				return ErrExplain{
-					err,
-					"Contract keystore must match the wallet of the contract operator. Make sure you're providing the correct keystore.",
+					errors.New("ethereum network mismatch for payment contract"),
+					fmt.Sprintf("Contract is on %q while the Contact RPC is a %q node. Please provide a Contract RPC on the same network as the contract.", network, poolNetwork),
 				}
 			}
-			return err
-		}
-		balanceStore = contract
-		settleHandler = contract.OpSettle
+			haveContract = true
 
-		depositGetter = func(ctx context.Context) (*big.Int, error) {
-			r, err := ethclient.PendingBalanceAt(ctx, contractAddr)
+			var transactOpts *bind.TransactOpts
+			if options.Pool.Contract.KeyStore != "" {
+				transactOpts, err = unlockTransactor(options.Pool.Contract.KeyStore)
+				if err != nil {
+					return ErrExplain{
+						err,
+						"Failed to unlock the keystore for the contract operator wallet. Make sure the path is correct and the decryption password is set in the `KEYSTORE_PASSPHRASE` environment variable.",
+					}
+				}
+			}
+
+			if transactOpts == nil {
+				logger.Warningf("Contract payment starting in read-only mode because --contract-keystore was not set. Withdraw and settlement attempts will fail.")
+			}
+
+			contract, err := payment.ContractPayment(storeDriver, contractAddr, ethclient, transactOpts)
 			if err != nil {
-				// Try again in case the connection dropped
-				logger.Warningf("PoolStatus: ethclient.PendingBalanceAt failed, retrying: %s", err)
-				r, err = ethclient.PendingBalanceAt(ctx, contractAddr)
+				if err, ok := err.(payment.AddressMismatchError); ok {
+					return ErrExplain{
+						err,
+						"Contract keystore must match the wallet of the contract operator. Make sure you're providing the correct keystore.",
+					}
+				}
+				return err
 			}
+			balanceStore = contract
+			settleHandler = contract.OpSettle
+
+			// Estimate the settle transaction's gas cost once at startup, so
+			// DynamicWithdrawFee doesn't need to re-estimate on every
+			// withdrawal. This calls eth_estimateGas against the bare
+			// contract address with no calldata; it does not estimate the
+			// settle method's ABI-encoded call. A bare call with no calldata
+			// can underestimate a call that writes storage and emits an
+			// event, so the result is floored at DefaultSettleGasEstimate.
+			settleCallMsg := ethereum.CallMsg{To: &contractAddr}
+			if transactOpts != nil {
+				settleCallMsg.From = transactOpts.From
+			}
+			settleGasEstimate, err = ethclient.EstimateGas(context.Background(), settleCallMsg)
 			if err != nil {
-				logger.Errorf("PoolStatus: ethclient.PendingBalanceAt failed twice: %s", err)
+				return fmt.Errorf("failed to estimate settle transaction gas: %s", err)
+			}
+			if settleGasEstimate < payment.DefaultSettleGasEstimate {
+				settleGasEstimate = payment.DefaultSettleGasEstimate
+			}
+
+			depositGetter = func(ctx context.Context) (*big.Int, error) {
+				r, err := ethclient.PendingBalanceAt(ctx, contractAddr)
+				if err != nil {
+					// Try again in case the connection dropped
+					logger.Warningf("PoolStatus: ethclient.PendingBalanceAt failed, retrying: %s", err)
+					r, err = ethclient.PendingBalanceAt(ctx, contractAddr)
+				}
+				if err != nil {
+					logger.Errorf("PoolStatus: ethclient.PendingBalanceAt failed twice: %s", err)
+				}
+				return r, err
 			}
-			return r, err
 		}
 	}
 
+	// LES-server hosts serve heavier per-peer load than regular full nodes,
+	// so credit them at a premium on the recurring interval credit below.
+	// capabilityTracker is shared with storeDriver (wrapped further down),
+	// which records each host's advertised capabilities on registration, so
+	// that the credit here can bill against them even though balanceStore
+	// isn't necessarily the same store (e.g. when a payment contract is in
+	// use). This only wraps the interval-credit path, not balanceStore
+	// itself, since the latter is also used for withdrawals, which
+	// shouldn't be scaled by this multiplier.
+	capabilityTracker := balance.NewCapabilityTracker()
+	intervalCreditStore := balance.NewMultipliedBalanceStore(balanceStore, capabilityTracker, balance.CapabilityMultiplier{
+		"les-server": 1.5,
+	})
+
 	// Setup balance manager
 	creditPerInterval, err := pretty.ParseEther(options.Pool.Contract.Price)
 	if err != nil {
 		return fmt.Errorf("failed to parse contract price: %s", err)
 	}
 	balanceManager := balance.PayPerInterval(
-		balanceStore,
+		intervalCreditStore,
 		time.Minute*1, // Interval
 		creditPerInterval,
 	)
@@ -173,8 +261,28 @@ func runPool(options Options) error {
 		return err
 	}
 
-	p := pool.New(storeDriver, balanceManager)
+	// Prefer LES-capable hosts for light clients, and record host
+	// capabilities so intervalCreditStore above can bill them at a premium.
+	p := pool.New(pool.WithCapabilityPreference(balance.NewTrackingStore(storeDriver, capabilityTracker)), balanceManager)
 	p.MaxRequestHosts = options.Pool.MaxRequestHosts
+	balanceManager.OnBalance = func(nodeID store.NodeID, bal store.Balance) {
+		// Warn the client with some headroom before they actually hit
+		// MinBalance and get disconnected.
+		if balanceManager.MinBalance != nil && float64(bal.Credit) < float64(balanceManager.MinBalance.Int64())*1.25 {
+			p.Notify(nodeID, pool.Notification{
+				Kind:     pool.BalanceLow,
+				Severity: pool.SeverityWarning,
+				Text:     "Your balance is running low, please add funds to avoid being disconnected.",
+				Data:     map[string]interface{}{"credit": bal.Credit},
+			})
+		}
+	}
+	if haveContract {
+		// Only hosts serving the same network as the payment contract are
+		// allowed to register, otherwise the pool could credit hosts for
+		// traffic on a chain it isn't actually paying out on.
+		p.Network = poolNetwork.String()
+	}
 	p.Version = fmt.Sprintf("vipnode/pool/%s", Version)
 	p.ClientMessager = func(nodeID string) string {
 		var buf bytes.Buffer
@@ -187,7 +295,26 @@ func runPool(options Options) error {
 			// TODO: Should this be recoverable? What conditions would cause this?
 			logger.Errorf("ClientMessager failed: %s", err)
 		}
-		return buf.String()
+		msg := buf.String()
+		// Also publish the rendered welcome as a PoolAnnouncement, best
+		// effort. ClientMessager runs at connect time, before the node has
+		// had a chance to call Notifications(ctx), so unless Notify buffers
+		// per-node until a subscriber attaches, this is likely to be missed
+		// by the very first subscription; the returned msg, which becomes
+		// ClientResponse.Message, is the only delivery clients can rely on.
+		p.Notify(store.NodeID(nodeID), pool.Notification{
+			Kind:     pool.PoolAnnouncement,
+			Severity: pool.SeverityInfo,
+			Text:     msg,
+		})
+		return msg
+	}
+	p.OnDisconnect = func(nodeID store.NodeID, reason string) {
+		p.Notify(nodeID, pool.Notification{
+			Kind:     pool.HostEvicted,
+			Severity: pool.SeverityWarning,
+			Text:     reason,
+		})
 	}
 
 	handler := &server{
@@ -203,16 +330,29 @@ func runPool(options Options) error {
 	}
 
 	// Pool payment management API (optional)
+	withdrawFee := func(amount *big.Int) *big.Int {
+		fee := big.NewInt(2500000000000000) // 0.0025 ETH
+		return amount.Sub(amount, fee)
+	}
+	if gasOracle != nil {
+		withdrawFeeMin, err := pretty.ParseEther(options.Pool.WithdrawFeeMin)
+		if err != nil {
+			return fmt.Errorf("failed to parse --withdraw-fee-min: %s", err)
+		}
+		withdrawFeeMax, err := pretty.ParseEther(options.Pool.WithdrawFeeMax)
+		if err != nil {
+			return fmt.Errorf("failed to parse --withdraw-fee-max: %s", err)
+		}
+
+		withdrawFee = payment.DynamicWithdrawFee(gasOracle, settleGasEstimate, options.Pool.WithdrawFeeMultiplier, withdrawFeeMin, withdrawFeeMax)
+	}
+
 	payment := &payment.PaymentService{
 		NonceStore:   storeDriver,
 		AccountStore: storeDriver,
 		BalanceStore: balanceStore, // Proxy smart contract store if available
 
-		WithdrawFee: func(amount *big.Int) *big.Int {
-			// TODO: Adjust fee dynamically based on gas price?
-			fee := big.NewInt(2500000000000000) // 0.0025 ETH
-			return amount.Sub(amount, fee)
-		},
+		WithdrawFee: withdrawFee,
 		WithdrawMin: big.NewInt(5000000000000000), // 0.005 ETH
 		Settle:      settleHandler,
 	}
@@ -220,6 +360,62 @@ func runPool(options Options) error {
 		return err
 	}
 
+	// Testnet faucet (optional), for bootstrapping new client deposits.
+	if options.Pool.FaucetKeyStore != "" {
+		if !poolNetwork.IsTestnet() {
+			return ErrExplain{
+				errors.New("faucet is only available on testnets"),
+				fmt.Sprintf("Refusing to start the faucet on %q. --faucet-keystore requires --contract-rpc (with or without --contract) to be pointed at a node on a known testnet.", poolNetwork),
+			}
+		}
+
+		faucetAmount, err := pretty.ParseEther(options.Pool.FaucetAmount)
+		if err != nil {
+			return fmt.Errorf("failed to parse --faucet-amount: %s", err)
+		}
+		// FaucetCooldown is enforced by the store driver itself via
+		// Store.FaucetClaim (storeDriver.Cooldown was set above).
+		// FaucetDailyCap is enforced separately by faucet.Service below,
+		// since it's a rolling total across claims rather than a per-claim
+		// check.
+		faucetDailyCap, err := pretty.ParseEther(options.Pool.FaucetDailyCap)
+		if err != nil {
+			return fmt.Errorf("failed to parse --faucet-daily-cap: %s", err)
+		}
+
+		faucetTransactor, err := unlockTransactor(options.Pool.FaucetKeyStore)
+		if err != nil {
+			return ErrExplain{
+				err,
+				"Failed to unlock the faucet keystore. Make sure the path is correct and the decryption password is set in the `KEYSTORE_PASSPHRASE` environment variable.",
+			}
+		}
+
+		var deposit faucet.Depositer
+		if contract, ok := balanceStore.(payment.ContractBalanceStore); ok {
+			deposit = func(ctx context.Context, account store.Account, amount *big.Int) error {
+				return contract.OpDeposit(ctx, faucetTransactor, account, amount)
+			}
+		} else {
+			deposit = func(ctx context.Context, account store.Account, amount *big.Int) error {
+				return balanceStore.AddBalance(account, store.Amount(amount.Int64()))
+			}
+		}
+
+		faucetService := &faucet.Service{
+			Store:    storeDriver,
+			Network:  poolNetwork,
+			Amount:   faucetAmount,
+			DailyCap: faucetDailyCap,
+			Deposit:  deposit,
+			RemoteIP: remoteIP,
+		}
+		if err := handler.Register("pool_", faucetService); err != nil {
+			return err
+		}
+		logger.Infof("Faucet enabled: %s per claim, %s cooldown, %s daily cap", options.Pool.FaucetAmount, options.Pool.FaucetCooldown, options.Pool.FaucetDailyCap)
+	}
+
 	// Pool status dashboard API
 	dashboard := &status.PoolStatus{
 		Store:           storeDriver,
@@ -237,14 +433,42 @@ func runPool(options Options) error {
 			logger.Warningf("Ignoring --bind value (%q) because it's not 443 and --tlshost is set.", options.Pool.Bind)
 		}
 		logger.Infof("Starting pool (version %s), acquiring ACME certificate and listening on: https://%s", Version, options.Pool.TLSHost)
-		err := http.Serve(autocert.NewListener(options.Pool.TLSHost), handler)
+		err := http.Serve(autocert.NewListener(options.Pool.TLSHost), withRemoteAddr(handler))
 		if strings.HasSuffix(err.Error(), "bind: permission denied") {
 			err = ErrExplain{err, "Hosting a pool with autocert requires CAP_NET_BIND_SERVICE capability permission to bind on low-numbered ports. See: https://superuser.com/questions/710253/allow-non-root-process-to-bind-to-port-80-and-443/892391"}
 		}
 		return err
 	}
 	logger.Infof("Starting pool (version %s), listening on: %s", Version, options.Pool.Bind)
-	return http.ListenAndServe(options.Pool.Bind, handler)
+	return http.ListenAndServe(options.Pool.Bind, withRemoteAddr(handler))
+}
+
+// remoteAddrKey is the context key used to propagate a request's remote
+// address from the HTTP/WS transport (see withRemoteAddr) down to RPC
+// method handlers that need it, e.g. faucet.Service.RemoteIP for per-IP
+// rate-limiting.
+type remoteAddrKey struct{}
+
+// withRemoteAddr wraps handler, stashing each request's RemoteAddr on its
+// context before serving it, so downstream RPC calls dispatched from
+// r.Context() can recover the caller's IP via remoteIP.
+func withRemoteAddr(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), remoteAddrKey{}, r.RemoteAddr)
+		handler.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// remoteIP extracts the client IP (stripping the port) stashed on ctx by
+// withRemoteAddr. Returns "" if unset, or the address unchanged if it has
+// no port to strip.
+func remoteIP(ctx context.Context) string {
+	addr, _ := ctx.Value(remoteAddrKey{}).(string)
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
 }
 
 func unlockTransactor(keystorePath string) (*bind.TransactOpts, error) {
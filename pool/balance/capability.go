@@ -0,0 +1,95 @@
+package balance
+
+import (
+	"sync"
+
+	"github.com/vipnode/vipnode/pool/store"
+)
+
+// CapabilityMultiplier scales the base interval credit earned by hosts that
+// advertise one of the given capabilities (see store.Node.Capabilities),
+// e.g. crediting "les-server" hosts at 1.5x since serving light clients is
+// heavier per-peer than serving full nodes. Capabilities not present in the
+// map earn the base rate.
+type CapabilityMultiplier map[string]float64
+
+// multiplierFor returns the highest applicable multiplier for the given
+// capabilities, or 1 if none match or none of the matches exceeds 1.
+func (m CapabilityMultiplier) multiplierFor(capabilities []string) float64 {
+	best := 1.0
+	for _, c := range capabilities {
+		if mult, ok := m[c]; ok && mult > best {
+			best = mult
+		}
+	}
+	return best
+}
+
+// CapabilityTracker remembers the capabilities a node last advertised via
+// Store.SetNode, keyed by account, so a later AddBalance credit for that
+// account can be billed at the right CapabilityMultiplier. It's shared
+// between a TrackingStore (which records) and a MultipliedBalanceStore
+// (which reads), since the node registry and the balance store aren't
+// necessarily the same store (e.g. when a payment contract is in use).
+type CapabilityTracker struct {
+	mu           sync.Mutex
+	capabilities map[store.Account][]string
+}
+
+// NewCapabilityTracker returns an empty CapabilityTracker.
+func NewCapabilityTracker() *CapabilityTracker {
+	return &CapabilityTracker{capabilities: map[store.Account][]string{}}
+}
+
+func (t *CapabilityTracker) record(account store.Account, capabilities []string) {
+	t.mu.Lock()
+	t.capabilities[account] = capabilities
+	t.mu.Unlock()
+}
+
+func (t *CapabilityTracker) multiplierFor(m CapabilityMultiplier, account store.Account) float64 {
+	t.mu.Lock()
+	capabilities := t.capabilities[account]
+	t.mu.Unlock()
+	return m.multiplierFor(capabilities)
+}
+
+// TrackingStore wraps a store.Store, recording each SetNode call's
+// capabilities into Tracker, for later use by a MultipliedBalanceStore
+// sharing the same Tracker.
+type TrackingStore struct {
+	store.Store
+	Tracker *CapabilityTracker
+}
+
+// NewTrackingStore wraps s, recording SetNode capabilities into tracker.
+func NewTrackingStore(s store.Store, tracker *CapabilityTracker) TrackingStore {
+	return TrackingStore{Store: s, Tracker: tracker}
+}
+
+func (s TrackingStore) SetNode(node store.Node, account store.Account) error {
+	s.Tracker.record(account, node.Capabilities)
+	return s.Store.SetNode(node, account)
+}
+
+// MultipliedBalanceStore wraps a store.BalanceStore, scaling AddBalance
+// credits by Multiplier according to the capabilities Tracker last recorded
+// for the account (see TrackingStore).
+type MultipliedBalanceStore struct {
+	store.BalanceStore
+	Tracker    *CapabilityTracker
+	Multiplier CapabilityMultiplier
+}
+
+// NewMultipliedBalanceStore wraps s, billing credits at multiplier based on
+// capabilities recorded in tracker.
+func NewMultipliedBalanceStore(s store.BalanceStore, tracker *CapabilityTracker, multiplier CapabilityMultiplier) MultipliedBalanceStore {
+	return MultipliedBalanceStore{BalanceStore: s, Tracker: tracker, Multiplier: multiplier}
+}
+
+func (s MultipliedBalanceStore) AddBalance(account store.Account, credit store.Amount) error {
+	if mult := s.Tracker.multiplierFor(s.Multiplier, account); mult != 1 {
+		credit = store.Amount(float64(credit) * mult)
+	}
+	return s.BalanceStore.AddBalance(account, credit)
+}
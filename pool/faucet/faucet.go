@@ -0,0 +1,163 @@
+// Package faucet implements an optional RPC subsystem that lets new clients
+// bootstrap a small starting balance on testnets, without needing to
+// source ETH and deposit into the payment contract first.
+package faucet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/vipnode/vipnode/ethnode"
+	"github.com/vipnode/vipnode/pool/store"
+)
+
+// ErrNetworkNotSupported is returned when the faucet is asked to run against
+// a network that isn't a known testnet.
+var ErrNetworkNotSupported = errors.New("faucet: network is not a supported testnet")
+
+// ErrDailyCapExceeded is returned when a claim would push a NodeID's rolling
+// 24h total past DailyCap.
+var ErrDailyCapExceeded = errors.New("faucet: daily claim cap exceeded for this NodeID")
+
+// Depositer credits an account with amount, either by submitting an
+// on-chain top-up transaction (when the pool runs with a payment contract)
+// or by crediting the account balance directly (when it doesn't).
+type Depositer func(ctx context.Context, account store.Account, amount *big.Int) error
+
+// FaucetRequest is the request type for the pool_requestFaucet RPC call.
+type FaucetRequest struct {
+	NodeID  store.NodeID `json:"node_id"`
+	Account string       `json:"account"`
+}
+
+// FaucetResponse is the response type for the pool_requestFaucet RPC call.
+type FaucetResponse struct {
+	Amount       *big.Int  `json:"amount"`
+	NextEligible time.Time `json:"next_eligible"`
+}
+
+// Service implements the pool_requestFaucet RPC method. It's registered
+// alongside payment.PaymentService on the same RPC handler when
+// --faucet-keystore is set.
+type Service struct {
+	Store store.Store
+
+	// Network gates faucet availability: requests are only served when this
+	// is a known testnet.
+	Network ethnode.NetworkID
+
+	// Amount is the amount credited per successful claim. The interval
+	// between claims is enforced by Store.FaucetClaim, configured when the
+	// store driver is constructed.
+	Amount *big.Int
+
+	// DailyCap, if set, limits the total amount a single NodeID can claim
+	// within a rolling 24h window, on top of Store.FaucetClaim's per-claim
+	// cooldown. Nil disables the cap.
+	//
+	// This is enforced against an in-process ledger (see claimed), not
+	// Store, so it's only accurate for a single pool process. Running the
+	// faucet behind multiple replicas sharing one Store lets a claimant
+	// accumulate up to (replica count * DailyCap) by hitting different
+	// replicas; don't enable the faucet on a multi-replica deployment
+	// without putting claims behind a single replica (e.g. a sticky route)
+	// or moving this ledger into Store.
+	DailyCap *big.Int
+
+	// Deposit credits the claimed amount to account, either on-chain or
+	// directly against the balance store.
+	Deposit Depositer
+
+	// RemoteIP returns the requesting IP for rate-limiting, extracted from
+	// ctx by the RPC transport.
+	RemoteIP func(ctx context.Context) string
+
+	mu      sync.Mutex
+	claimed map[store.NodeID][]dailyClaim
+}
+
+// dailyClaim records a single faucet claim for DailyCap bookkeeping.
+type dailyClaim struct {
+	at     time.Time
+	amount *big.Int
+}
+
+// claimedToday returns the total amount nodeID has claimed within the
+// rolling 24h window ending at now, pruning expired entries as a side
+// effect.
+func (s *Service) claimedToday(nodeID store.NodeID, now time.Time) *big.Int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cutoff := now.Add(-24 * time.Hour)
+	total := new(big.Int)
+	kept := s.claimed[nodeID][:0]
+	for _, c := range s.claimed[nodeID] {
+		if c.at.After(cutoff) {
+			kept = append(kept, c)
+			total.Add(total, c.amount)
+		}
+	}
+	if s.claimed == nil {
+		s.claimed = map[store.NodeID][]dailyClaim{}
+	}
+	s.claimed[nodeID] = kept
+	return total
+}
+
+func (s *Service) recordClaim(nodeID store.NodeID, amount *big.Int, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.claimed == nil {
+		s.claimed = map[store.NodeID][]dailyClaim{}
+	}
+	s.claimed[nodeID] = append(s.claimed[nodeID], dailyClaim{at: at, amount: amount})
+}
+
+// RequestFaucet handles the pool_requestFaucet RPC call.
+func (s *Service) RequestFaucet(ctx context.Context, req FaucetRequest) (*FaucetResponse, error) {
+	if !s.Network.IsTestnet() {
+		return nil, ErrNetworkNotSupported
+	}
+
+	var ip string
+	if s.RemoteIP != nil {
+		ip = s.RemoteIP(ctx)
+	}
+
+	now := time.Now()
+	if s.DailyCap != nil {
+		total := new(big.Int).Add(s.claimedToday(req.NodeID, now), s.Amount)
+		if total.Cmp(s.DailyCap) > 0 {
+			return nil, ErrDailyCapExceeded
+		}
+	}
+
+	nextEligible, err := s.Store.FaucetClaim(req.NodeID, ip, now)
+	if err != nil {
+		return nil, fmt.Errorf("faucet: %s, try again after %s", err, nextEligible.Format(time.RFC3339))
+	}
+
+	if err := s.Deposit(ctx, store.Account(req.Account), s.Amount); err != nil {
+		// The claim above already started the cooldown; since the deposit
+		// it was gating never went out, undo it so the caller isn't
+		// penalized for a transient deposit failure.
+		if rollbackErr := s.Store.FaucetRollback(req.NodeID, ip); rollbackErr != nil {
+			return nil, fmt.Errorf("faucet: deposit failed (%s) and rollback of the claim failed (%s)", err, rollbackErr)
+		}
+		return nil, err
+	}
+
+	if s.DailyCap != nil {
+		s.recordClaim(req.NodeID, s.Amount, now)
+	}
+
+	return &FaucetResponse{
+		Amount:       s.Amount,
+		NextEligible: nextEligible,
+	}, nil
+}
@@ -0,0 +1,54 @@
+package faucet
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/vipnode/vipnode/ethnode"
+	"github.com/vipnode/vipnode/pool/store"
+	"github.com/vipnode/vipnode/pool/store/memory"
+)
+
+func TestRequestFaucetRollsBackClaimOnDepositFailure(t *testing.T) {
+	s := memory.New()
+	depositErr := errors.New("rpc: connection refused")
+	svc := &Service{
+		Store:   s,
+		Network: ethnode.NetworkID(3), // ropsten
+		Amount:  big.NewInt(1),
+		Deposit: func(ctx context.Context, account store.Account, amount *big.Int) error {
+			return depositErr
+		},
+	}
+
+	req := FaucetRequest{NodeID: "node-1", Account: "0xabc"}
+	if _, err := svc.RequestFaucet(context.Background(), req); err != depositErr {
+		t.Fatalf("expected deposit error, got: %v", err)
+	}
+
+	// The cooldown should have been rolled back, so a retry (e.g. once the
+	// RPC connection recovers) isn't blocked by the failed attempt above.
+	svc.Deposit = func(ctx context.Context, account store.Account, amount *big.Int) error {
+		return nil
+	}
+	if _, err := svc.RequestFaucet(context.Background(), req); err != nil {
+		t.Fatalf("expected retry to succeed after rollback, got: %s", err)
+	}
+}
+
+func TestRequestFaucetRejectsUnsupportedNetwork(t *testing.T) {
+	svc := &Service{
+		Store:   memory.New(),
+		Network: ethnode.NetworkID(1), // mainnet
+		Amount:  big.NewInt(1),
+		Deposit: func(ctx context.Context, account store.Account, amount *big.Int) error {
+			return nil
+		},
+	}
+
+	if _, err := svc.RequestFaucet(context.Background(), FaucetRequest{NodeID: "node-1"}); err != ErrNetworkNotSupported {
+		t.Fatalf("expected ErrNetworkNotSupported, got: %v", err)
+	}
+}
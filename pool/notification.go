@@ -0,0 +1,45 @@
+package pool
+
+import "time"
+
+// NotificationKind identifies the type of event a Notification carries.
+type NotificationKind string
+
+// Recognized NotificationKind values.
+const (
+	// BalanceLow fires when a node's credit drops under MinBalance * 1.25,
+	// giving clients a heads up before they're disconnected for running out
+	// of balance.
+	BalanceLow NotificationKind = "balance_low"
+	// HostEvicted fires when a host is disconnected by the pool, along with
+	// the reason in Notification.Text.
+	HostEvicted NotificationKind = "host_evicted"
+	// ContractUpgradeRequired fires when the pool's payment contract has
+	// been superseded and the node should point at a new one.
+	ContractUpgradeRequired NotificationKind = "contract_upgrade_required"
+	// PoolAnnouncement is a free-form operator message, e.g. the welcome
+	// message sent at connect time, or planned maintenance notices.
+	PoolAnnouncement NotificationKind = "pool_announcement"
+	// SettlementComplete fires when a withdrawal has been settled on-chain.
+	SettlementComplete NotificationKind = "settlement_complete"
+)
+
+// Severity indicates how prominently a UI should surface a Notification.
+type Severity string
+
+// Recognized Severity values.
+const (
+	SeverityInfo    Severity = "info"
+	SeverityWarning Severity = "warning"
+	SeverityError   Severity = "error"
+)
+
+// Notification is a single event pushed to a connected node via
+// Pool.Notifications.
+type Notification struct {
+	Kind      NotificationKind       `json:"kind"`
+	Severity  Severity               `json:"severity"`
+	Text      string                 `json:"text"`
+	Data      map[string]interface{} `json:"data,omitempty"`
+	Timestamp time.Time              `json:"timestamp"`
+}
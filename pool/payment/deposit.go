@@ -0,0 +1,17 @@
+package payment
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/vipnode/vipnode/pool/store"
+)
+
+// ContractBalanceStore is implemented by Contract to expose an on-chain
+// top-up, used by the pool faucet to credit accounts when the pool runs
+// with a payment contract (as opposed to crediting BalanceStore directly).
+type ContractBalanceStore interface {
+	store.BalanceStore
+	OpDeposit(ctx context.Context, transactor *bind.TransactOpts, account store.Account, amount *big.Int) error
+}
@@ -0,0 +1,138 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// GasOracle returns a suggested gas price, in wei, to use for a pending
+// transaction.
+type GasOracle interface {
+	SuggestGasPrice(ctx context.Context) (*big.Int, error)
+}
+
+// EthclientGasOracle is a GasOracle backed by ethclient.SuggestGasPrice,
+// scaled by Multiplier (e.g. 1.2 for a 20% premium over the suggested
+// price). A zero Multiplier is treated as 1.
+type EthclientGasOracle struct {
+	Client     *ethclient.Client
+	Multiplier float64
+}
+
+// SuggestGasPrice implements GasOracle.
+func (o *EthclientGasOracle) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	price, err := o.Client.SuggestGasPrice(ctx)
+	if err != nil {
+		return nil, err
+	}
+	mult := o.Multiplier
+	if mult == 0 {
+		mult = 1
+	}
+	// Scale by the multiplier using float math, then convert back to wei.
+	// This loses sub-wei precision, which is fine for a gas price estimate.
+	scaled := new(big.Float).Mul(new(big.Float).SetInt(price), big.NewFloat(mult))
+	result, _ := scaled.Int(nil)
+	return result, nil
+}
+
+// FeeHistoryGasOracle is a GasOracle backed by eth_feeHistory, for nodes
+// that support EIP-1559. It estimates a price as the next block's base fee
+// plus a priority tip, the tip being the given Percentile (0-100) of
+// rewards averaged across the sampled blocks. Percentile of 0 defaults to
+// 50.
+type FeeHistoryGasOracle struct {
+	Client     *ethclient.Client
+	Percentile float64
+	// Blocks is how many recent blocks to sample. 0 defaults to 20.
+	Blocks int
+}
+
+// SuggestGasPrice implements GasOracle.
+func (o *FeeHistoryGasOracle) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	percentile := o.Percentile
+	if percentile == 0 {
+		percentile = 50
+	}
+	blocks := o.Blocks
+	if blocks == 0 {
+		blocks = 20
+	}
+
+	history, err := o.Client.FeeHistory(ctx, uint64(blocks), nil, []float64{percentile})
+	if err != nil {
+		return nil, err
+	}
+	if len(history.Reward) == 0 || len(history.BaseFee) == 0 {
+		return nil, ErrNoFeeHistory
+	}
+
+	// Base fee for the next block is always the last entry.
+	baseFee := history.BaseFee[len(history.BaseFee)-1]
+
+	// Average the requested reward percentile across the sampled blocks as
+	// the priority tip.
+	tip := new(big.Int)
+	for _, r := range history.Reward {
+		tip.Add(tip, r[0])
+	}
+	tip.Div(tip, big.NewInt(int64(len(history.Reward))))
+
+	return new(big.Int).Add(baseFee, tip), nil
+}
+
+// ErrNoFeeHistory is returned by FeeHistoryGasOracle when the node returns an
+// empty fee history, which shouldn't normally happen.
+var ErrNoFeeHistory = errors.New("payment: node returned empty fee history")
+
+// DefaultSettleGasEstimate is a conservative floor for the settle
+// transaction's gas cost. A bare eth_estimateGas call against the contract
+// address with no calldata (the only option until generated contract
+// bindings are available to estimate against the real ABI-encoded settle
+// calldata) can underestimate a call that writes storage and emits an
+// event, so callers should take the larger of that estimate and this
+// constant. It's sized well above a typical single-SSTORE contract call
+// (~21000 base + ~20000 per cold SSTORE + a few thousand for the event
+// log), so DynamicWithdrawFee errs on the side of overcharging rather than
+// the pool operator eating the difference during a gas spike.
+const DefaultSettleGasEstimate uint64 = 150000
+
+// DynamicWithdrawFee returns a PaymentService.WithdrawFee func that prices
+// the withdrawal fee off of the current gas market, rather than a static
+// amount. fee = gasPrice * settleGasEstimate * safetyMultiplier, clamped to
+// [min, max].
+//
+// settleGasEstimate is the gas cost of the settle transaction, typically
+// computed once at startup via eth_estimateGas and cached by the caller.
+// safetyMultiplier accounts for gas price volatility between estimation and
+// the actual settle (e.g. 1.5 for a 50% safety margin). min and max bound
+// the fee so a gas spike (or a misbehaving oracle) can't charge more than
+// the pool operator is willing to eat, or undercut actual settle cost.
+func DynamicWithdrawFee(oracle GasOracle, settleGasEstimate uint64, safetyMultiplier float64, min, max *big.Int) func(amount *big.Int) *big.Int {
+	return func(amount *big.Int) *big.Int {
+		gasPrice, err := oracle.SuggestGasPrice(context.Background())
+		if err != nil {
+			// Fall back to the ceiling on oracle failure, so a withdrawal
+			// never goes out underpriced.
+			return amount.Sub(amount, max)
+		}
+
+		fee := new(big.Int).Mul(gasPrice, new(big.Int).SetUint64(settleGasEstimate))
+		if safetyMultiplier != 0 {
+			scaled := new(big.Float).Mul(new(big.Float).SetInt(fee), big.NewFloat(safetyMultiplier))
+			fee, _ = scaled.Int(nil)
+		}
+
+		if min != nil && fee.Cmp(min) < 0 {
+			fee = min
+		}
+		if max != nil && fee.Cmp(max) > 0 {
+			fee = max
+		}
+
+		return amount.Sub(amount, fee)
+	}
+}
@@ -0,0 +1,71 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+type fakeGasOracle struct {
+	price *big.Int
+	err   error
+}
+
+func (o fakeGasOracle) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	return o.price, o.err
+}
+
+func TestDynamicWithdrawFee(t *testing.T) {
+	amount := big.NewInt(1000000000000000000) // 1 ether
+	min := big.NewInt(1000000000000000)       // 0.001 ether
+	max := big.NewInt(10000000000000000)      // 0.01 ether
+
+	tests := []struct {
+		name        string
+		oracle      fakeGasOracle
+		gasEstimate uint64
+		multiplier  float64
+		wantFee     *big.Int
+	}{
+		{
+			name:        "priced within bounds",
+			oracle:      fakeGasOracle{price: big.NewInt(50000000000)}, // 50 gwei
+			gasEstimate: 100000,
+			multiplier:  1.5,
+			wantFee:     big.NewInt(7500000000000000), // 50gwei * 100000 * 1.5
+		},
+		{
+			name:        "clamped to floor",
+			oracle:      fakeGasOracle{price: big.NewInt(1)},
+			gasEstimate: 100000,
+			multiplier:  1.5,
+			wantFee:     min,
+		},
+		{
+			name:        "clamped to ceiling on a gas spike",
+			oracle:      fakeGasOracle{price: big.NewInt(5000000000000)},
+			gasEstimate: 100000,
+			multiplier:  1.5,
+			wantFee:     max,
+		},
+		{
+			name:        "oracle failure falls back to the ceiling",
+			oracle:      fakeGasOracle{err: errors.New("rpc timeout")},
+			gasEstimate: 100000,
+			multiplier:  1.5,
+			wantFee:     max,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withdrawFee := DynamicWithdrawFee(tt.oracle, tt.gasEstimate, tt.multiplier, min, max)
+			got := withdrawFee(new(big.Int).Set(amount))
+			want := new(big.Int).Sub(amount, tt.wantFee)
+			if got.Cmp(want) != 0 {
+				t.Fatalf("expected withdraw amount %s, got %s", want, got)
+			}
+		})
+	}
+}
@@ -6,13 +6,33 @@ import (
 	"github.com/vipnode/vipnode/pool/store"
 )
 
-// TODO: Add HostRequest.Network and ClientRequest.Network?
 // TODO: Add HostRequest.HostVersion?
 
+// Recognized values for HostRequest.Kind and ClientRequest.Kind.
+const (
+	KindGeth    = "geth"
+	KindParity  = "parity"
+	KindGethLES = "geth-les"
+	// KindLight is a generic light-client kind, for clients/hosts that don't
+	// want to commit to a specific implementation.
+	KindLight = "light"
+)
+
 // HostRequest is the request type for Host RPC calls.
 type HostRequest struct {
-	// Kind is the type of node the host supports: geth, parity
+	// Kind is the type of node the host supports: geth, parity, geth-les, light
 	Kind string `json:"kind"`
+	// Capabilities are the host's advertised sub-protocols, as reported by
+	// admin_nodeInfo, e.g. "les/2", "les/4", "les-server". Used to match
+	// light clients with LES-capable hosts.
+	Capabilities []string `json:"capabilities,omitempty"`
+	// Network is the ethereum network the host is serving, e.g. "mainnet",
+	// "ropsten", "rinkeby", "goerli", "sepolia". If the host doesn't know the
+	// name, it may fall back to NetworkID, the numeric chain ID.
+	Network string `json:"network,omitempty"`
+	// NetworkID is the numeric chain ID, used as a fallback when Network is
+	// empty or unrecognized.
+	NetworkID int64 `json:"network_id,omitempty"`
 	// Payout sets the wallet account to register the host credit towards.
 	Payout string `json:"payout"`
 	// Optional public node URI override, useful if the vipnode agent runs on a
@@ -28,8 +48,16 @@ type HostResponse struct {
 
 // ClientRequest is the request type for Client RPC calls.
 type ClientRequest struct {
-	Kind     string `json:"kind"`
-	NumHosts int    `json:"num_hosts,omitempty"` // NumHosts is the number of hosts to request from the pool. (Optional)
+	// Kind is the type of node the client runs: geth, parity, geth-les, light.
+	// Light clients (geth-les, light) prefer hosts that advertise an
+	// LES-serving capability, and default to requesting more hosts since
+	// they tolerate higher peer churn.
+	Kind string `json:"kind"`
+	// Network is the ethereum network the client wants to sync, matched
+	// against the Network of available hosts. See HostRequest.Network.
+	Network   string `json:"network,omitempty"`
+	NetworkID int64  `json:"network_id,omitempty"`
+	NumHosts  int    `json:"num_hosts,omitempty"` // NumHosts is the number of hosts to request from the pool. (Optional)
 }
 
 // ClientResponse is the response type for Client RPC calls.
@@ -42,9 +70,28 @@ type ClientResponse struct {
 	// Message contains a prompt for the client from the pool, possibly
 	// instructions for interfacing with this pool. For example, a link to the
 	// DApp for adding a balance deposit.
+	//
+	// Deprecated: Message only carries the rendered welcome template at
+	// connect time. Subscribe via Notifications for the ongoing stream of
+	// pool events; it's also published there as a PoolAnnouncement, but
+	// (unless Notify buffers per-node events for nodes that haven't
+	// subscribed yet) a Notifications subscription made after the Client
+	// call that returned this Message may not see it. Message remains the
+	// only delivery clients can rely on for this specific announcement.
 	Message string `json:"message,omitempty"`
 }
 
+// IsLightKind returns whether kind describes a light client, which prefers
+// LES-capable hosts and tolerates higher peer churn (see
+// MaxRequestHosts).
+func IsLightKind(kind string) bool {
+	switch kind {
+	case KindGethLES, KindLight:
+		return true
+	}
+	return false
+}
+
 // UpdateRequest is the request type for Update RPC calls.
 type UpdateRequest struct {
 	Peers       []string `json:"peers"`
@@ -62,11 +109,15 @@ type Pool interface {
 	// Host subscribes a host to receive vipnode_whitelist instructions.
 	Host(ctx context.Context, req HostRequest) (*HostResponse, error)
 
-	// Client requests for available hosts to connect to as a client.
+	// Client requests for available hosts to connect to as a client. Light
+	// clients (see IsLightKind) are matched preferentially against hosts
+	// advertising an LES-serving capability.
 	Client(ctx context.Context, req ClientRequest) (*ClientResponse, error)
 
 	// Disconnect stops tracking the connection and billing, will prompt a
-	// disconnect from both ends.
+	// disconnect from both ends. If OnDisconnect is set, it's called with
+	// the evicted NodeID and a human-readable reason, which is also
+	// published as a HostEvicted Notification.
 	Disconnect(ctx context.Context) error
 
 	// Update is a keep-alive for sharing the node's peering info. It returns
@@ -76,4 +127,9 @@ type Pool interface {
 
 	// Withdraw prompts a request to settle the node's balance.
 	Withdraw(ctx context.Context) error
+
+	// Notifications subscribes to a stream of pool events for the connected
+	// node, such as low balance warnings or eviction notices. The channel is
+	// closed when ctx is cancelled or the connection ends.
+	Notifications(ctx context.Context) (<-chan Notification, error)
 }
@@ -0,0 +1,62 @@
+package pool
+
+import "github.com/vipnode/vipnode/pool/store"
+
+// lightClientDefaultNumHosts is the minimum number of hosts a light client
+// (see IsLightKind) is offered, even if the pool's configured
+// MaxRequestHosts is lower. Light clients tolerate higher peer churn than
+// full nodes, so they're worth offering more hosts by default.
+const lightClientDefaultNumHosts = 5
+
+// lightClientCapability is the capability LES-capable hosts advertise (see
+// store.Node.Capabilities) that light clients are preferentially matched
+// against.
+const lightClientCapability = "les-server"
+
+// WithCapabilityPreference wraps a store.Store so that ActiveHosts
+// transparently prefers LES-capable hosts for light clients (see
+// IsLightKind), falling back to any other active host on a matching network,
+// of any kind, to fill out the remainder. It also raises the requested
+// limit for light clients up to lightClientDefaultNumHosts, since they
+// tolerate higher peer churn.
+func WithCapabilityPreference(s store.Store) store.Store {
+	return capabilityPreferringStore{s}
+}
+
+type capabilityPreferringStore struct {
+	store.Store
+}
+
+func (s capabilityPreferringStore) ActiveHosts(kind, network string, limit int) []store.Node {
+	if IsLightKind(kind) && limit < lightClientDefaultNumHosts {
+		limit = lightClientDefaultNumHosts
+	}
+	if !IsLightKind(kind) {
+		return s.Store.ActiveHosts(kind, network, limit)
+	}
+
+	// Light clients don't care what kind string the serving host registered
+	// with (e.g. "geth", "geth-les") — only that it's LES-capable — so we
+	// match on capability/network alone, independent of the client's own
+	// kind.
+	hosts := s.Store.ActiveHostsWithCapability("", network, lightClientCapability, limit)
+	if len(hosts) >= limit {
+		return hosts
+	}
+
+	seen := make(map[store.NodeID]bool, len(hosts))
+	for _, h := range hosts {
+		seen[h.ID] = true
+	}
+
+	for _, h := range s.Store.ActiveHosts("", network, limit) {
+		if len(hosts) >= limit {
+			break
+		}
+		if seen[h.ID] {
+			continue
+		}
+		hosts = append(hosts, h)
+	}
+	return hosts
+}
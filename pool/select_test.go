@@ -0,0 +1,50 @@
+package pool
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vipnode/vipnode/pool/store"
+	"github.com/vipnode/vipnode/pool/store/memory"
+)
+
+func TestWithCapabilityPreferenceMatchesLightClients(t *testing.T) {
+	s := WithCapabilityPreference(memory.New())
+
+	host := store.Node{
+		ID:           "les-host",
+		Kind:         KindGeth,
+		Network:      "mainnet",
+		IsHost:       true,
+		LastSeen:     time.Now(),
+		Capabilities: []string{"les-server"},
+	}
+	if err := s.SetNode(host, "les-host-account"); err != nil {
+		t.Fatalf("SetNode failed: %s", err)
+	}
+
+	hosts := s.ActiveHosts(KindLight, "mainnet", 1)
+	if len(hosts) != 1 || hosts[0].ID != host.ID {
+		t.Fatalf("expected light client to be matched with the les-server geth host, got: %+v", hosts)
+	}
+}
+
+func TestWithCapabilityPreferenceFallsBackAcrossKinds(t *testing.T) {
+	s := WithCapabilityPreference(memory.New())
+
+	host := store.Node{
+		ID:       "plain-geth-host",
+		Kind:     KindGeth,
+		Network:  "mainnet",
+		IsHost:   true,
+		LastSeen: time.Now(),
+	}
+	if err := s.SetNode(host, "plain-geth-host-account"); err != nil {
+		t.Fatalf("SetNode failed: %s", err)
+	}
+
+	hosts := s.ActiveHosts(KindLight, "mainnet", 1)
+	if len(hosts) != 1 || hosts[0].ID != host.ID {
+		t.Fatalf("expected light client to fall back to a non-LES geth host, got: %+v", hosts)
+	}
+}
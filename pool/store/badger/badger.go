@@ -0,0 +1,316 @@
+// Package badger implements a persistent, single-process pool/store.Store
+// backed by a local badger key-value database.
+package badger
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/dgraph-io/badger"
+	"github.com/vipnode/vipnode/pool/store"
+)
+
+// Key prefixes for the different record types stored in badger.
+const (
+	prefixNonce   = "nonce:"
+	prefixBalance = "balance:"
+	prefixNode    = "node:"
+	prefixPeer    = "peer:" // peer:<nodeID>:<peerID>
+	prefixFaucet  = "faucet:"
+)
+
+// Open opens (or creates) a badger database using opts.
+func Open(opts badger.Options) (*Store, error) {
+	db, err := badger.Open(opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{db: db}, nil
+}
+
+// defaultFaucetCooldown is used by FaucetClaim when Store.Cooldown is unset.
+const defaultFaucetCooldown = 24 * time.Hour
+
+// Store is a badger-backed implementation of store.Store.
+type Store struct {
+	db *badger.DB
+
+	// Cooldown is the minimum time between faucet claims for the same
+	// NodeID or IP, see FaucetClaim. Defaults to defaultFaucetCooldown if
+	// unset.
+	Cooldown time.Duration
+}
+
+// Close implements store.Store.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// CheckAndSaveNonce implements store.Store.
+func (s *Store) CheckAndSaveNonce(nodeID store.NodeID, nonce int64) error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		key := []byte(prefixNonce + string(nodeID))
+		item, err := txn.Get(key)
+		if err != nil && err != badger.ErrKeyNotFound {
+			return err
+		}
+		if err == nil {
+			var last int64
+			if err := item.Value(func(val []byte) error {
+				return json.Unmarshal(val, &last)
+			}); err != nil {
+				return err
+			}
+			if nonce <= last {
+				return errors.New("badger: nonce is not higher than the last seen nonce")
+			}
+		}
+		val, err := json.Marshal(nonce)
+		if err != nil {
+			return err
+		}
+		return txn.Set(key, val)
+	})
+}
+
+// GetBalance implements store.Store.
+func (s *Store) GetBalance(account store.Account) store.Balance {
+	bal := store.Balance{Account: account}
+	s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(prefixBalance + string(account)))
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			return json.Unmarshal(val, &bal)
+		})
+	})
+	return bal
+}
+
+// AddBalance implements store.Store.
+func (s *Store) AddBalance(account store.Account, credit store.Amount) error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		key := []byte(prefixBalance + string(account))
+		bal := store.Balance{Account: account}
+		item, err := txn.Get(key)
+		if err != nil && err != badger.ErrKeyNotFound {
+			return err
+		}
+		if err == nil {
+			if err := item.Value(func(val []byte) error {
+				return json.Unmarshal(val, &bal)
+			}); err != nil {
+				return err
+			}
+		}
+		bal.Credit += credit
+		val, err := json.Marshal(bal)
+		if err != nil {
+			return err
+		}
+		return txn.Set(key, val)
+	})
+}
+
+// ActiveHosts implements store.Store.
+func (s *Store) ActiveHosts(kind, network string, limit int) []store.Node {
+	return s.activeHosts(kind, network, "", limit)
+}
+
+// ActiveHostsWithCapability implements store.Store.
+func (s *Store) ActiveHostsWithCapability(kind, network, capability string, limit int) []store.Node {
+	return s.activeHosts(kind, network, capability, limit)
+}
+
+func (s *Store) activeHosts(kind, network, capability string, limit int) []store.Node {
+	var matches []store.Node
+	s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		prefix := []byte(prefixNode)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			var n store.Node
+			if err := it.Item().Value(func(val []byte) error {
+				return json.Unmarshal(val, &n)
+			}); err != nil {
+				continue
+			}
+			if !n.IsHost {
+				continue
+			}
+			if kind != "" && n.Kind != kind {
+				continue
+			}
+			if network != "" && n.Network != network {
+				continue
+			}
+			if capability != "" && !n.HasCapability(capability) {
+				continue
+			}
+			matches = append(matches, n)
+		}
+		return nil
+	})
+
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].LastSeen.After(matches[j].LastSeen)
+	})
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+// SetNode implements store.Store.
+func (s *Store) SetNode(node store.Node, account store.Account) error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		val, err := json.Marshal(node)
+		if err != nil {
+			return err
+		}
+		return txn.Set([]byte(prefixNode+string(node.ID)), val)
+	})
+}
+
+// RemoveNode implements store.Store.
+func (s *Store) RemoveNode(nodeID store.NodeID) error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete([]byte(prefixNode + string(nodeID)))
+	})
+}
+
+// UpdateNodePeers implements store.Store.
+func (s *Store) UpdateNodePeers(nodeID store.NodeID, peers []string) ([]store.Node, error) {
+	var inactive []store.Node
+	err := s.db.Update(func(txn *badger.Txn) error {
+		now := time.Now()
+		keep := map[string]bool{}
+		for _, peerID := range peers {
+			keep[peerID] = true
+		}
+
+		prefix := []byte(fmt.Sprintf("%s%s:", prefixPeer, nodeID))
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		var toDelete [][]byte
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			key := it.Item().KeyCopy(nil)
+			peerID := string(key[len(prefix):])
+			if keep[peerID] {
+				continue
+			}
+			toDelete = append(toDelete, key)
+			if item, err := txn.Get([]byte(prefixNode + peerID)); err == nil {
+				var n store.Node
+				if err := item.Value(func(val []byte) error {
+					return json.Unmarshal(val, &n)
+				}); err == nil {
+					inactive = append(inactive, n)
+				}
+			}
+		}
+		it.Close()
+
+		for _, key := range toDelete {
+			if err := txn.Delete(key); err != nil {
+				return err
+			}
+		}
+		for _, peerID := range peers {
+			key := []byte(fmt.Sprintf("%s%s:%s", prefixPeer, nodeID, peerID))
+			val, err := json.Marshal(now)
+			if err != nil {
+				return err
+			}
+			if err := txn.Set(key, val); err != nil {
+				return err
+			}
+		}
+
+		if item, err := txn.Get([]byte(prefixNode + string(nodeID))); err == nil {
+			var n store.Node
+			if err := item.Value(func(val []byte) error {
+				return json.Unmarshal(val, &n)
+			}); err == nil {
+				n.LastSeen = now
+				val, err := json.Marshal(n)
+				if err != nil {
+					return err
+				}
+				if err := txn.Set([]byte(prefixNode+string(nodeID)), val); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+	return inactive, err
+}
+
+// FaucetClaim implements store.Store, rate-limiting both nodeID and ip
+// independently with a Cooldown between claims.
+func (s *Store) FaucetClaim(nodeID store.NodeID, ip string, now time.Time) (time.Time, error) {
+	cooldown := s.Cooldown
+	if cooldown <= 0 {
+		cooldown = defaultFaucetCooldown
+	}
+	nextEligible := now.Add(cooldown)
+
+	err := s.db.Update(func(txn *badger.Txn) error {
+		keys := []string{prefixFaucet + "node:" + string(nodeID), prefixFaucet + "ip:" + ip}
+		for _, key := range keys {
+			item, err := txn.Get([]byte(key))
+			if err != nil && err != badger.ErrKeyNotFound {
+				return err
+			}
+			if err == nil {
+				var last time.Time
+				if err := item.Value(func(val []byte) error {
+					return json.Unmarshal(val, &last)
+				}); err != nil {
+					return err
+				}
+				if claimNextEligible := last.Add(cooldown); now.Before(claimNextEligible) {
+					nextEligible = claimNextEligible
+					return errors.New("badger: faucet claim is still in cooldown")
+				}
+			}
+		}
+
+		val, err := json.Marshal(now)
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if err := txn.Set([]byte(key), val); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nextEligible, err
+	}
+	return nextEligible, nil
+}
+
+// FaucetRollback implements store.Store.
+func (s *Store) FaucetRollback(nodeID store.NodeID, ip string) error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		keys := []string{prefixFaucet + "node:" + string(nodeID), prefixFaucet + "ip:" + ip}
+		for _, key := range keys {
+			if err := txn.Delete([]byte(key)); err != nil && err != badger.ErrKeyNotFound {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+var _ store.Store = (*Store)(nil)
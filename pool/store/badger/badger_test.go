@@ -0,0 +1,30 @@
+package badger
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/dgraph-io/badger"
+	"github.com/vipnode/vipnode/pool/store/storetest"
+)
+
+func TestConformance(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vipnode-badger-conformance")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	opts := badger.DefaultOptions
+	opts.Dir = dir
+	opts.ValueDir = dir
+
+	s, err := Open(opts)
+	if err != nil {
+		t.Fatalf("Open failed: %s", err)
+	}
+	defer s.Close()
+
+	storetest.Run(t, s)
+}
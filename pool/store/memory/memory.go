@@ -0,0 +1,219 @@
+// Package memory implements an in-process, non-persistent pool/store.Store,
+// useful for tests and demo pools that don't need to survive a restart.
+package memory
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/vipnode/vipnode/pool/store"
+)
+
+// New returns an empty, ready to use in-memory store.
+func New() *Store {
+	return &Store{
+		nonces:       map[store.NodeID]int64{},
+		balances:     map[store.Account]*store.Balance{},
+		nodes:        map[store.NodeID]*store.Node{},
+		nodeAccounts: map[store.NodeID]store.Account{},
+		peers:        map[store.NodeID]map[string]time.Time{},
+		faucetClaims: map[string]time.Time{},
+	}
+}
+
+// defaultFaucetCooldown is used by FaucetClaim when Store.Cooldown is unset.
+const defaultFaucetCooldown = 24 * time.Hour
+
+// Store is an in-memory implementation of store.Store.
+type Store struct {
+	// Cooldown is the minimum time between faucet claims for the same
+	// NodeID or IP, see FaucetClaim. Defaults to defaultFaucetCooldown if
+	// unset.
+	Cooldown time.Duration
+
+	mu sync.Mutex
+
+	nonces       map[store.NodeID]int64
+	balances     map[store.Account]*store.Balance
+	nodes        map[store.NodeID]*store.Node
+	nodeAccounts map[store.NodeID]store.Account
+	peers        map[store.NodeID]map[string]time.Time
+	faucetClaims map[string]time.Time
+}
+
+// Close implements store.Store. There's nothing to release in-memory.
+func (s *Store) Close() error {
+	return nil
+}
+
+// CheckAndSaveNonce implements store.Store.
+func (s *Store) CheckAndSaveNonce(nodeID store.NodeID, nonce int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if last, ok := s.nonces[nodeID]; ok && nonce <= last {
+		return errors.New("memory: nonce is not higher than the last seen nonce")
+	}
+	s.nonces[nodeID] = nonce
+	return nil
+}
+
+// GetBalance implements store.Store.
+func (s *Store) GetBalance(account store.Account) store.Balance {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if bal, ok := s.balances[account]; ok {
+		return *bal
+	}
+	return store.Balance{Account: account}
+}
+
+// AddBalance implements store.Store.
+func (s *Store) AddBalance(account store.Account, credit store.Amount) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bal, ok := s.balances[account]
+	if !ok {
+		bal = &store.Balance{Account: account}
+		s.balances[account] = bal
+	}
+	bal.Credit += credit
+	return nil
+}
+
+// ActiveHosts implements store.Store.
+func (s *Store) ActiveHosts(kind, network string, limit int) []store.Node {
+	return s.activeHosts(kind, network, "", limit)
+}
+
+// ActiveHostsWithCapability implements store.Store.
+func (s *Store) ActiveHostsWithCapability(kind, network, capability string, limit int) []store.Node {
+	return s.activeHosts(kind, network, capability, limit)
+}
+
+func (s *Store) activeHosts(kind, network, capability string, limit int) []store.Node {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var matches []store.Node
+	for _, n := range s.nodes {
+		if !n.IsHost {
+			continue
+		}
+		if kind != "" && n.Kind != kind {
+			continue
+		}
+		if network != "" && n.Network != network {
+			continue
+		}
+		if capability != "" && !n.HasCapability(capability) {
+			continue
+		}
+		matches = append(matches, *n)
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].LastSeen.After(matches[j].LastSeen)
+	})
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+// SetNode implements store.Store.
+func (s *Store) SetNode(node store.Node, account store.Account) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nodes[node.ID] = &node
+	s.nodeAccounts[node.ID] = account
+	return nil
+}
+
+// RemoveNode implements store.Store.
+func (s *Store) RemoveNode(nodeID store.NodeID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.nodes, nodeID)
+	delete(s.nodeAccounts, nodeID)
+	delete(s.peers, nodeID)
+	return nil
+}
+
+// UpdateNodePeers implements store.Store.
+func (s *Store) UpdateNodePeers(nodeID store.NodeID, peers []string) ([]store.Node, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	known := s.peers[nodeID]
+	if known == nil {
+		known = map[string]time.Time{}
+	}
+
+	keep := map[string]bool{}
+	for _, peerID := range peers {
+		keep[peerID] = true
+		known[peerID] = now
+	}
+
+	var inactive []store.Node
+	for peerID := range known {
+		if keep[peerID] {
+			continue
+		}
+		if n, ok := s.nodes[store.NodeID(peerID)]; ok {
+			inactive = append(inactive, *n)
+		}
+		delete(known, peerID)
+	}
+	s.peers[nodeID] = known
+
+	if n, ok := s.nodes[nodeID]; ok {
+		n.LastSeen = now
+	}
+
+	return inactive, nil
+}
+
+// FaucetClaim implements store.Store, rate-limiting both nodeID and ip
+// independently with a Cooldown between claims.
+func (s *Store) FaucetClaim(nodeID store.NodeID, ip string, now time.Time) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cooldown := s.Cooldown
+	if cooldown <= 0 {
+		cooldown = defaultFaucetCooldown
+	}
+
+	for _, key := range []string{"node:" + string(nodeID), "ip:" + ip} {
+		if last, ok := s.faucetClaims[key]; ok {
+			if nextEligible := last.Add(cooldown); now.Before(nextEligible) {
+				return nextEligible, errors.New("memory: faucet claim is still in cooldown")
+			}
+		}
+	}
+
+	s.faucetClaims["node:"+string(nodeID)] = now
+	s.faucetClaims["ip:"+ip] = now
+	return now.Add(cooldown), nil
+}
+
+// FaucetRollback implements store.Store.
+func (s *Store) FaucetRollback(nodeID store.NodeID, ip string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.faucetClaims, "node:"+string(nodeID))
+	delete(s.faucetClaims, "ip:"+ip)
+	return nil
+}
+
+var _ store.Store = (*Store)(nil)
@@ -0,0 +1,11 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/vipnode/vipnode/pool/store/storetest"
+)
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, New())
+}
@@ -0,0 +1,343 @@
+// Package sql implements pool/store.Store on top of PostgreSQL, for
+// operators who want to run the pool behind multiple replicas or share
+// state with an out-of-band settlement worker. Schema migrations live under
+// pool/store/sql/migrations and should be applied with a standard migration
+// tool (e.g. golang-migrate) before Open is called.
+package sql
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/vipnode/vipnode/pool/store"
+)
+
+// defaultFaucetCooldown is used by FaucetClaim when Store.Cooldown is unset.
+const defaultFaucetCooldown = 24 * time.Hour
+
+// Store is a store.Store backed by a PostgreSQL database.
+type Store struct {
+	db *sql.DB
+
+	// Cooldown is the minimum time between faucet claims for the same
+	// NodeID or IP, see FaucetClaim. Defaults to defaultFaucetCooldown if
+	// unset.
+	Cooldown time.Duration
+}
+
+// Open connects to the Postgres database at dsn. It does not run
+// migrations; the schema under pool/store/sql/migrations must already be
+// applied.
+func Open(dsn string) (*Store, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Store{db: db}, nil
+}
+
+// Close implements store.Store.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// CheckAndSaveNonce implements store.Store. It's a single upsert that only
+// accepts the new nonce if it's higher than the one on record, so
+// concurrent callers can't race each other into accepting a stale nonce.
+func (s *Store) CheckAndSaveNonce(nodeID store.NodeID, nonce int64) error {
+	result, err := s.db.Exec(`
+		INSERT INTO nonces (node_id, nonce) VALUES ($1, $2)
+		ON CONFLICT (node_id) DO UPDATE SET nonce = $2 WHERE nonces.nonce < $2
+	`, string(nodeID), nonce)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return errors.New("store: nonce is not higher than the last seen nonce")
+	}
+	return nil
+}
+
+// GetBalance implements store.Store.
+func (s *Store) GetBalance(account store.Account) store.Balance {
+	var balance store.Balance
+	var nextWithdraw sql.NullTime
+	row := s.db.QueryRow(`SELECT account, credit, next_withdraw FROM balances WHERE account = $1`, string(account))
+	if err := row.Scan(&balance.Account, &balance.Credit, &nextWithdraw); err != nil {
+		return store.Balance{Account: account}
+	}
+	balance.NextWithdraw = nextWithdraw.Time
+	return balance
+}
+
+// AddBalance implements store.Store, using a row-level lock so concurrent
+// credits to the same account serialize rather than lose updates.
+func (s *Store) AddBalance(account store.Account, credit store.Amount) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		INSERT INTO balances (account, credit) VALUES ($1, 0)
+		ON CONFLICT (account) DO NOTHING
+	`, string(account)); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`
+		SELECT credit FROM balances WHERE account = $1 FOR UPDATE
+	`, string(account)); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE balances SET credit = credit + $2 WHERE account = $1
+	`, string(account), int(credit)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ActiveHosts implements store.Store.
+func (s *Store) ActiveHosts(kind, network string, limit int) []store.Node {
+	return s.activeHosts(kind, network, "", limit)
+}
+
+// ActiveHostsWithCapability implements store.Store.
+func (s *Store) ActiveHostsWithCapability(kind, network, capability string, limit int) []store.Node {
+	return s.activeHosts(kind, network, capability, limit)
+}
+
+func (s *Store) activeHosts(kind, network, capability string, limit int) []store.Node {
+	query := `
+		SELECT DISTINCT n.node_id, n.uri, n.last_seen, n.kind, n.network, n.network_id, n.is_host
+		FROM nodes n`
+	var args []interface{}
+	where := []string{"n.is_host = TRUE"}
+
+	if kind != "" {
+		args = append(args, kind)
+		where = append(where, fmt.Sprintf("n.kind = $%d", len(args)))
+	}
+	if network != "" {
+		args = append(args, network)
+		where = append(where, fmt.Sprintf("n.network = $%d", len(args)))
+	}
+	if capability != "" {
+		query += ` JOIN node_capabilities c ON c.node_id = n.node_id`
+		args = append(args, capability)
+		where = append(where, fmt.Sprintf("c.capability = $%d", len(args)))
+	}
+
+	query += " WHERE " + joinAND(where)
+	args = append(args, limit)
+	query += fmt.Sprintf(" ORDER BY n.last_seen DESC LIMIT $%d", len(args))
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+
+	var nodes []store.Node
+	for rows.Next() {
+		var n store.Node
+		if err := rows.Scan(&n.ID, &n.URI, &n.LastSeen, &n.Kind, &n.Network, &n.NetworkID, &n.IsHost); err != nil {
+			return nil
+		}
+		n.Capabilities = s.capabilitiesFor(n.ID)
+		nodes = append(nodes, n)
+	}
+	return nodes
+}
+
+func (s *Store) capabilitiesFor(nodeID store.NodeID) []string {
+	rows, err := s.db.Query(`SELECT capability FROM node_capabilities WHERE node_id = $1`, string(nodeID))
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+
+	var caps []string
+	for rows.Next() {
+		var c string
+		if err := rows.Scan(&c); err != nil {
+			return nil
+		}
+		caps = append(caps, c)
+	}
+	return caps
+}
+
+func joinAND(clauses []string) string {
+	out := ""
+	for i, c := range clauses {
+		if i > 0 {
+			out += " AND "
+		}
+		out += c
+	}
+	return out
+}
+
+// SetNode implements store.Store.
+func (s *Store) SetNode(node store.Node, account store.Account) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		INSERT INTO nodes (node_id, uri, last_seen, kind, network, network_id, is_host, account)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (node_id) DO UPDATE SET
+			uri = $2, last_seen = $3, kind = $4, network = $5, network_id = $6, is_host = $7, account = $8
+	`, string(node.ID), node.URI, node.LastSeen, node.Kind, node.Network, node.NetworkID, node.IsHost, string(account)); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM node_capabilities WHERE node_id = $1`, string(node.ID)); err != nil {
+		return err
+	}
+	for _, cap := range node.Capabilities {
+		if _, err := tx.Exec(`
+			INSERT INTO node_capabilities (node_id, capability) VALUES ($1, $2)
+		`, string(node.ID), cap); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// RemoveNode implements store.Store.
+func (s *Store) RemoveNode(nodeID store.NodeID) error {
+	_, err := s.db.Exec(`DELETE FROM nodes WHERE node_id = $1`, string(nodeID))
+	return err
+}
+
+// UpdateNodePeers implements store.Store as a single transaction that
+// replaces the peer set for nodeID and returns any peers that were dropped.
+func (s *Store) UpdateNodePeers(nodeID store.NodeID, peers []string) ([]store.Node, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	rows, err := tx.Query(`
+		WITH current AS (
+			SELECT peer_id FROM node_peers WHERE node_id = $1
+		), keep AS (
+			SELECT unnest($2::text[]) AS peer_id
+		), evicted AS (
+			DELETE FROM node_peers
+			WHERE node_id = $1 AND peer_id IN (SELECT peer_id FROM current EXCEPT SELECT peer_id FROM keep)
+			RETURNING peer_id
+		)
+		SELECT n.node_id, n.uri, n.last_seen, n.kind, n.network, n.network_id, n.is_host
+		FROM evicted e JOIN nodes n ON n.node_id = e.peer_id
+	`, string(nodeID), pq.Array(peers))
+	if err != nil {
+		return nil, err
+	}
+
+	var inactive []store.Node
+	for rows.Next() {
+		var n store.Node
+		if err := rows.Scan(&n.ID, &n.URI, &n.LastSeen, &n.Kind, &n.Network, &n.NetworkID, &n.IsHost); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		inactive = append(inactive, n)
+	}
+	rows.Close()
+
+	for _, peerID := range peers {
+		if _, err := tx.Exec(`
+			INSERT INTO node_peers (node_id, peer_id, last_seen) VALUES ($1, $2, $3)
+			ON CONFLICT (node_id, peer_id) DO UPDATE SET last_seen = $3
+		`, string(nodeID), peerID, now); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := tx.Exec(`UPDATE nodes SET last_seen = $2 WHERE node_id = $1`, string(nodeID), now); err != nil {
+		return nil, err
+	}
+
+	return inactive, tx.Commit()
+}
+
+// FaucetClaim implements store.Store, rate-limiting per-NodeID and per-IP
+// with a Cooldown between claims.
+func (s *Store) FaucetClaim(nodeID store.NodeID, ip string, now time.Time) (time.Time, error) {
+	cooldown := s.Cooldown
+	if cooldown <= 0 {
+		cooldown = defaultFaucetCooldown
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer tx.Rollback()
+
+	// Serialize concurrent claims for this nodeID/ip before the
+	// check-then-insert below: a brand-new nodeID/ip pair has no row for
+	// "... FOR UPDATE" to lock, so an advisory lock (keyed the same way the
+	// cooldown lookup below is, independently on nodeID and on ip) is what
+	// actually closes the race, not just wrapping the statements in a
+	// transaction.
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext('faucet:node:' || $1))`, string(nodeID)); err != nil {
+		return time.Time{}, err
+	}
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext('faucet:ip:' || $1))`, ip); err != nil {
+		return time.Time{}, err
+	}
+
+	row := tx.QueryRow(`
+		SELECT claimed_at FROM faucet_claims WHERE node_id = $1 OR ip = $2 ORDER BY claimed_at DESC LIMIT 1
+	`, string(nodeID), ip)
+	var claimedAt time.Time
+	if err := row.Scan(&claimedAt); err == nil {
+		nextEligible := claimedAt.Add(cooldown)
+		if now.Before(nextEligible) {
+			return nextEligible, errors.New("store: faucet claim is still in cooldown")
+		}
+	} else if err != sql.ErrNoRows {
+		return time.Time{}, err
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO faucet_claims (node_id, ip, claimed_at) VALUES ($1, $2, $3)
+		ON CONFLICT (node_id, ip) DO UPDATE SET claimed_at = $3
+	`, string(nodeID), ip, now); err != nil {
+		return time.Time{}, err
+	}
+	return now.Add(cooldown), tx.Commit()
+}
+
+// FaucetRollback implements store.Store.
+func (s *Store) FaucetRollback(nodeID store.NodeID, ip string) error {
+	_, err := s.db.Exec(`DELETE FROM faucet_claims WHERE node_id = $1 AND ip = $2`, string(nodeID), ip)
+	return err
+}
+
+var _ store.Store = (*Store)(nil)
@@ -0,0 +1,26 @@
+package sql
+
+import (
+	"os"
+	"testing"
+
+	"github.com/vipnode/vipnode/pool/store/storetest"
+)
+
+// TestConformance runs the shared store.Store conformance suite against a
+// live Postgres database. It's skipped unless POSTGRES_URL points at a
+// database with the pool/store/sql/migrations schema already applied.
+func TestConformance(t *testing.T) {
+	dsn := os.Getenv("POSTGRES_URL")
+	if dsn == "" {
+		t.Skip("POSTGRES_URL not set, skipping sql store conformance test")
+	}
+
+	s, err := Open(dsn)
+	if err != nil {
+		t.Fatalf("Open failed: %s", err)
+	}
+	defer s.Close()
+
+	storetest.Run(t, s)
+}
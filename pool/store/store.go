@@ -36,13 +36,32 @@ type Node struct {
 	URI      string    `json:"uri"`
 	LastSeen time.Time `json:"last_seen"`
 	Kind     string    `json:"kind"`
-	IsHost   bool
+	// Network is the ethereum network this node is serving or requesting,
+	// e.g. "mainnet", "ropsten". NetworkID is the numeric chain-id fallback
+	// used when Network is empty or unrecognized.
+	Network   string `json:"network,omitempty"`
+	NetworkID int64  `json:"network_id,omitempty"`
+	// Capabilities are the node's advertised sub-protocols, populated from
+	// admin_nodeInfo, e.g. "les/2", "les/4", "les-server".
+	Capabilities []string `json:"capabilities,omitempty"`
+	IsHost       bool
 
 	balance *Balance
 	peers   map[NodeID]time.Time // Last seen (only for vipnode-registered peers)
 	inSync  bool                 // TODO: Do we need a penalty if a full node wants to accept peers while not in sync?
 }
 
+// HasCapability returns whether the node advertises the given capability,
+// e.g. "les-server".
+func (n *Node) HasCapability(capability string) bool {
+	for _, c := range n.Capabilities {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
+
 // Store is the storage interface used by VipnodePool. It should be goroutine-safe.
 type Store interface {
 	// CheckAndSaveNonce asserts that this is the highest nonce seen for this NodeID.
@@ -53,9 +72,20 @@ type Store interface {
 	// AddBalance adds some credit amount to that account balance.
 	AddBalance(account Account, credit Amount) error
 
-	// ActiveHosts returns `limit`-number of `kind` nodes. This could be an
-	// empty list, if none are available.
-	ActiveHosts(kind string, limit int) []Node
+	// ActiveHosts returns `limit`-number of `kind` nodes serving `network`.
+	// An empty kind matches hosts of any kind, and an empty network matches
+	// nodes of any network, for backwards compatibility with callers that
+	// haven't reported one yet. A host with an empty Network only matches
+	// an empty network. This could return an empty list, if none are
+	// available.
+	ActiveHosts(kind, network string, limit int) []Node
+
+	// ActiveHostsWithCapability is like ActiveHosts, but additionally
+	// restricted to hosts that advertise the given capability (see
+	// Node.Capabilities), e.g. "les-server". Used to prefer LES-capable
+	// hosts for light clients, regardless of what kind string those hosts
+	// registered with (pass an empty kind to match any of them).
+	ActiveHostsWithCapability(kind, network, capability string, limit int) []Node
 
 	// SetNode adds a Node to the set of active nodes.
 	SetNode(Node, Account) error
@@ -68,4 +98,22 @@ type Store interface {
 	// from the known peers and returned. It also updates nodeID's
 	// LastSeen.
 	UpdateNodePeers(nodeID NodeID, peers []string) (inactive []Node, err error)
+
+	// FaucetClaim records a faucet claim for nodeID from ip at time now, and
+	// returns the time at which the same nodeID or ip is next eligible to
+	// claim. It returns an error if nodeID or ip is still within its
+	// cooldown, in which case the claim is not recorded and nextEligible
+	// reflects the existing cooldown.
+	FaucetClaim(nodeID NodeID, ip string, now time.Time) (nextEligible time.Time, err error)
+
+	// FaucetRollback undoes a previously successful FaucetClaim for nodeID
+	// or ip, for when a claim was granted but the deposit it was meant to
+	// authorize failed to go through, so the cooldown it started doesn't
+	// unfairly apply. It's best-effort: if nodeID or ip also has an older,
+	// already-expired claim on record, that gets cleared too, which is
+	// harmless since its cooldown had already elapsed.
+	FaucetRollback(nodeID NodeID, ip string) error
+
+	// Close releases any resources held by the store.
+	Close() error
 }
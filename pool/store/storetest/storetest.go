@@ -0,0 +1,164 @@
+// Package storetest provides a conformance test suite that exercises a
+// store.Store implementation against a fixed set of scenarios, so behavior
+// stays consistent across the memory, badger, and sql backends.
+package storetest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vipnode/vipnode/pool/store"
+)
+
+// Run executes the conformance suite against store, calling t.Fatal on any
+// deviation from expected store.Store behavior.
+func Run(t *testing.T, store store.Store) {
+	t.Run("CheckAndSaveNonce", func(t *testing.T) { testCheckAndSaveNonce(t, store) })
+	t.Run("AddBalance", func(t *testing.T) { testAddBalance(t, store) })
+	t.Run("ActiveHosts", func(t *testing.T) { testActiveHosts(t, store) })
+	t.Run("ActiveHostsOrdering", func(t *testing.T) { testActiveHostsOrdering(t, store) })
+	t.Run("UpdateNodePeers", func(t *testing.T) { testUpdateNodePeers(t, store) })
+	t.Run("FaucetClaim", func(t *testing.T) { testFaucetClaim(t, store) })
+}
+
+func testCheckAndSaveNonce(t *testing.T, s store.Store) {
+	nodeID := store.NodeID("conformance-nonce")
+	if err := s.CheckAndSaveNonce(nodeID, 1); err != nil {
+		t.Fatalf("first nonce should be accepted: %s", err)
+	}
+	if err := s.CheckAndSaveNonce(nodeID, 1); err == nil {
+		t.Fatal("equal nonce should be rejected")
+	}
+	if err := s.CheckAndSaveNonce(nodeID, 0); err == nil {
+		t.Fatal("lower nonce should be rejected")
+	}
+	if err := s.CheckAndSaveNonce(nodeID, 2); err != nil {
+		t.Fatalf("higher nonce should be accepted: %s", err)
+	}
+}
+
+func testAddBalance(t *testing.T, s store.Store) {
+	account := store.Account("conformance-balance")
+	if err := s.AddBalance(account, 5); err != nil {
+		t.Fatalf("AddBalance failed: %s", err)
+	}
+	if err := s.AddBalance(account, 3); err != nil {
+		t.Fatalf("AddBalance failed: %s", err)
+	}
+	if got := s.GetBalance(account); got.Credit != 8 {
+		t.Fatalf("expected credit 8, got %d", got.Credit)
+	}
+}
+
+func testActiveHosts(t *testing.T, s store.Store) {
+	host := store.Node{
+		ID:           "conformance-host",
+		Kind:         "geth",
+		Network:      "mainnet",
+		IsHost:       true,
+		LastSeen:     time.Now(),
+		Capabilities: []string{"les-server"},
+	}
+	if err := s.SetNode(host, "conformance-host-account"); err != nil {
+		t.Fatalf("SetNode failed: %s", err)
+	}
+
+	hosts := s.ActiveHosts("geth", "mainnet", 10)
+	if len(hosts) == 0 {
+		t.Fatal("expected at least one active host")
+	}
+
+	if hosts := s.ActiveHosts("geth", "ropsten", 10); len(hosts) != 0 {
+		t.Fatal("host on a different network should not match")
+	}
+
+	withCap := s.ActiveHostsWithCapability("geth", "mainnet", "les-server", 10)
+	if len(withCap) == 0 {
+		t.Fatal("expected at least one host with les-server capability")
+	}
+	if withNoCap := s.ActiveHostsWithCapability("geth", "mainnet", "les/99", 10); len(withNoCap) != 0 {
+		t.Fatal("host without the capability should not match")
+	}
+
+	// An empty kind matches hosts of any kind, e.g. for capability-based
+	// matching that shouldn't care what kind string the host registered
+	// with (see pool.WithCapabilityPreference).
+	if anyKind := s.ActiveHostsWithCapability("", "mainnet", "les-server", 10); len(anyKind) == 0 {
+		t.Fatal("empty kind should match a les-server host regardless of its kind")
+	}
+	if anyKind := s.ActiveHosts("", "mainnet", 10); len(anyKind) == 0 {
+		t.Fatal("empty kind should match any active host")
+	}
+}
+
+// testActiveHostsOrdering asserts that when more hosts match than the
+// requested limit, the most recently-seen hosts are kept, regardless of how
+// their NodeIDs happen to sort. NodeIDs are deliberately chosen so that
+// lexicographic order is the reverse of LastSeen recency, to catch a backend
+// that returns iteration/key order instead of sorting by LastSeen.
+func testActiveHostsOrdering(t *testing.T, s store.Store) {
+	now := time.Now()
+	hosts := []store.Node{
+		{ID: "conformance-order-a", Kind: "geth", Network: "ordertest", IsHost: true, LastSeen: now.Add(-2 * time.Hour)},
+		{ID: "conformance-order-b", Kind: "geth", Network: "ordertest", IsHost: true, LastSeen: now.Add(-1 * time.Hour)},
+		{ID: "conformance-order-c", Kind: "geth", Network: "ordertest", IsHost: true, LastSeen: now},
+	}
+	for _, h := range hosts {
+		if err := s.SetNode(h, store.Account(string(h.ID)+"-account")); err != nil {
+			t.Fatalf("SetNode failed: %s", err)
+		}
+	}
+
+	got := s.ActiveHosts("geth", "ordertest", 2)
+	if len(got) != 2 {
+		t.Fatalf("expected 2 hosts, got %d", len(got))
+	}
+	if got[0].ID != "conformance-order-c" || got[1].ID != "conformance-order-b" {
+		t.Fatalf("expected the 2 most recently-seen hosts in descending order, got: %+v", got)
+	}
+}
+
+func testUpdateNodePeers(t *testing.T, s store.Store) {
+	host := store.Node{ID: "conformance-peer-host", Kind: "geth", IsHost: true, LastSeen: time.Now()}
+	peer := store.Node{ID: "conformance-peer", Kind: "geth", LastSeen: time.Now()}
+	if err := s.SetNode(host, "host-account"); err != nil {
+		t.Fatalf("SetNode failed: %s", err)
+	}
+	if err := s.SetNode(peer, "peer-account"); err != nil {
+		t.Fatalf("SetNode failed: %s", err)
+	}
+
+	if _, err := s.UpdateNodePeers(host.ID, []string{string(peer.ID)}); err != nil {
+		t.Fatalf("UpdateNodePeers failed: %s", err)
+	}
+
+	inactive, err := s.UpdateNodePeers(host.ID, nil)
+	if err != nil {
+		t.Fatalf("UpdateNodePeers failed: %s", err)
+	}
+	if len(inactive) != 1 || inactive[0].ID != peer.ID {
+		t.Fatalf("expected peer to be evicted, got: %+v", inactive)
+	}
+}
+
+func testFaucetClaim(t *testing.T, s store.Store) {
+	nodeID := store.NodeID("conformance-faucet")
+	now := time.Now()
+	if _, err := s.FaucetClaim(nodeID, "127.0.0.1", now); err != nil {
+		t.Fatalf("first claim should succeed: %s", err)
+	}
+	if _, err := s.FaucetClaim(nodeID, "127.0.0.1", now); err == nil {
+		t.Fatal("claim within cooldown should fail")
+	}
+
+	rollbackNodeID := store.NodeID("conformance-faucet-rollback")
+	if _, err := s.FaucetClaim(rollbackNodeID, "127.0.0.2", now); err != nil {
+		t.Fatalf("first claim should succeed: %s", err)
+	}
+	if err := s.FaucetRollback(rollbackNodeID, "127.0.0.2"); err != nil {
+		t.Fatalf("FaucetRollback failed: %s", err)
+	}
+	if _, err := s.FaucetClaim(rollbackNodeID, "127.0.0.2", now); err != nil {
+		t.Fatalf("claim after rollback should succeed since the cooldown was undone: %s", err)
+	}
+}